@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+)
+
+// ResolvedAddr is a single resolved address tagged with its IP family
+// (4 or 6) so per-family statistics and Happy Eyeballs interleaving can key
+// off it without re-parsing the address each time.
+type ResolvedAddr struct {
+	Addr   string
+	Family int
+}
+
+// familyStats are the Summary fields, tracked independently per IP family.
+type familyStats struct {
+	MAX, MIN, AVG, SUM time.Duration
+	Count, ErrCount    int
+}
+
+const (
+	// firstAttemptDelay is the RFC 8305 "Resolution Delay" before starting
+	// the second (opposite-family) connection attempt.
+	firstAttemptDelay = 300 * time.Millisecond
+	// staggerDelay paces every attempt after the second.
+	staggerDelay = 250 * time.Millisecond
+)
+
+// interleave sorts resolved addresses alternating IPv6/IPv4, per the "Sort
+// Destination Addresses" step of Happy Eyeballs v2 (RFC 8305 §4).
+func interleave(addrs []ResolvedAddr) []ResolvedAddr {
+	var v4, v6 []ResolvedAddr
+	for _, a := range addrs {
+		if a.Family == 6 {
+			v6 = append(v6, a)
+		} else {
+			v4 = append(v4, a)
+		}
+	}
+	out := make([]ResolvedAddr, 0, len(addrs))
+	for i := 0; i < len(v4) || i < len(v6); i++ {
+		if i < len(v6) {
+			out = append(out, v6[i])
+		}
+		if i < len(v4) {
+			out = append(out, v4[i])
+		}
+	}
+	return out
+}
+
+// pingAuto races connection attempts across every resolved address, Happy
+// Eyeballs v2 style: the first attempt starts immediately, the second
+// (opposite family) starts after firstAttemptDelay if nothing has connected
+// yet, and every attempt after that is staggered by staggerDelay. The first
+// successful connection wins and every other in-flight attempt is cancelled.
+func (p *Ping) pingAuto() *Stats {
+	addrs := interleave(p.resolved)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	type attempt struct {
+		addr ResolvedAddr
+		conn net.Conn
+		rtt  time.Duration
+		err  error
+	}
+	results := make(chan attempt, len(addrs))
+
+	go func() {
+		for i, addr := range addrs {
+			if i > 0 {
+				delay := staggerDelay
+				if i == 1 {
+					delay = firstAttemptDelay
+				}
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return
+				}
+			}
+			addr := addr
+			go func() {
+				start := time.Now()
+				dst := net.JoinHostPort(addr.Addr, strconv.Itoa(p.port))
+				conn, err := p.dialer.DialContext(ctx, p.net, dst)
+				rtt := time.Since(start)
+				select {
+				case results <- attempt{addr: addr, conn: conn, rtt: rtt, err: err}:
+				case <-ctx.Done():
+					if conn != nil {
+						conn.Close()
+					}
+				}
+			}()
+		}
+	}()
+
+	stats := &Stats{Host: p.host, Time: time.Now()}
+	var lastErr error
+	for i := 0; i < len(addrs); i++ {
+		select {
+		case a := <-results:
+			if a.err == nil {
+				cancel()
+				stats.Family = a.addr.Family
+				stats.DAddr = net.JoinHostPort(a.addr.Addr, strconv.Itoa(p.port))
+				stats.Duration = a.rtt
+				stats.SAddr = a.conn.LocalAddr().String()
+				a.conn.Close()
+				return stats
+			}
+			lastErr = a.err
+		case <-ctx.Done():
+			lastErr = ctx.Err()
+			i = len(addrs)
+		}
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no addresses to dial")
+	}
+	stats.Error = lastErr
+	return stats
+}
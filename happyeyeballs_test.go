@@ -0,0 +1,30 @@
+package main
+
+import "testing"
+
+func TestInterleaveAlternatesFamiliesV6First(t *testing.T) {
+	addrs := []ResolvedAddr{
+		{Addr: "203.0.113.1", Family: 4},
+		{Addr: "203.0.113.2", Family: 4},
+		{Addr: "2001:db8::1", Family: 6},
+	}
+
+	got := interleave(addrs)
+	want := []string{"2001:db8::1", "203.0.113.1", "203.0.113.2"}
+	if len(got) != len(want) {
+		t.Fatalf("interleave(%v) = %v, want %v", addrs, got, want)
+	}
+	for i, a := range got {
+		if a.Addr != want[i] {
+			t.Errorf("interleave(%v)[%d] = %q, want %q", addrs, i, a.Addr, want[i])
+		}
+	}
+}
+
+func TestInterleaveSingleFamily(t *testing.T) {
+	addrs := []ResolvedAddr{{Addr: "203.0.113.1", Family: 4}, {Addr: "203.0.113.2", Family: 4}}
+	got := interleave(addrs)
+	if len(got) != 2 || got[0].Addr != "203.0.113.1" || got[1].Addr != "203.0.113.2" {
+		t.Fatalf("interleave(%v) = %v, want unchanged order", addrs, got)
+	}
+}
@@ -7,31 +7,63 @@ import (
 	"fmt"
 	"net"
 	"os"
-	"os/signal"
 	"strconv"
 	"strings"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
 )
 
 var (
-	DefaultTimeout  = 3
-	DefaultHost     = ""
-	DefaultPort     = 80
-	DefaultNet      = "tcp"
-	DefaultInterval = 1
-	DefaultCount    = 10
-	DefaultDNSNet   = "udp"
-	DefaultDNSAddr  = ""
+	DefaultTimeout     = 3
+	DefaultPort        = 80
+	DefaultNet         = "tcp"
+	DefaultInterval    = 1
+	DefaultCount       = 10
+	DefaultDNSNet      = "udp"
+	DefaultDNSAddr     = ""
+	DefaultDNSQType    = "A"
+	DefaultDNSSNI      = ""
+	DefaultDNSInsecure = false
+	DefaultFamily      = "auto"
+	DefaultNetstack    = ""
+	DefaultOutput      = "text"
+	DefaultListen      = ""
+	DefaultTargetsFile = ""
+	DefaultParallel    = 0
+	Hosts              hostList
+
+	// qtypeExplicit records whether -qtype was actually passed on the
+	// command line, as opposed to defaulting to "A", so an explicit
+	// "-qtype A" or "-qtype AAAA" can override -family's address selection.
+	qtypeExplicit bool
 )
 
+// Dialer is satisfied by both *net.Dialer and golang.zx2c4.com/wireguard's
+// netstack.Net, so Ping can dial through either the host kernel or a
+// user-space WireGuard tunnel without caring which.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// HostResolver is an optional capability of a Dialer that can resolve
+// hostnames over the same network it dials through. netstack.Net (used by
+// -netstack) implements this against the tunnel's own configured DNS
+// servers; Ping.Resolver prefers it over the host's stub resolver, which is
+// usually unreachable from inside the tunnel.
+type HostResolver interface {
+	LookupContextHost(ctx context.Context, host string) ([]string, error)
+}
+
 type Ping struct {
-	net     string
-	host    string
-	addr    string
-	port    int
-	timeout int
+	net        string
+	host       string
+	resolved   []ResolvedAddr
+	addrIdx    int64
+	port       int
+	timeout    int
+	dialerOnce sync.Once
+	dialer     Dialer
 }
 
 type Stats struct {
@@ -40,6 +72,7 @@ type Stats struct {
 	Host     string
 	SAddr    string
 	DAddr    string
+	Family   int
 	Error    error
 }
 
@@ -54,53 +87,151 @@ type Summary struct {
 	Time     time.Time
 	Lock     *sync.Mutex
 	WG       *sync.WaitGroup
+	Families map[int]*familyStats
+	Addrs    map[string]*familyStats
+	Out      Output
+}
+
+// qtypesForFamily returns which DNS query types must be resolved to honour
+// the -family flag (auto and both need A and AAAA; 4/6 only need one).
+func qtypesForFamily(family string) []string {
+	switch family {
+	case "4":
+		return []string{"A"}
+	case "6":
+		return []string{"AAAA"}
+	default:
+		return []string{"A", "AAAA"}
+	}
 }
 
 func (p *Ping) Resolver() error {
-	var r *net.Resolver
-	var c bool
-	if DefaultDNSAddr != "" && DefaultDNSNet != "" {
-		dialer := &net.Dialer{}
-		r = &net.Resolver{
-			PreferGo:     true,
-			StrictErrors: false,
-			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-				return dialer.DialContext(ctx, strings.ToLower(DefaultDNSNet), DefaultDNSAddr)
-			},
-		}
-		c = true
-	} else {
-		r = &net.Resolver{}
+	p.normalize()
+	if DefaultDNSAddr != "" && DefaultDNSQType != "A" && DefaultDNSQType != "AAAA" {
+		resolver := NewResolver(DefaultDNSAddr, DefaultDNSNet, DefaultDNSQType, DefaultTimeout, DefaultDNSSNI, DefaultDNSInsecure)
+		result, err := resolver.Query(p.host)
+		if err != nil {
+			return err
+		}
+		if len(result.Addrs) == 0 {
+			return errors.New("not found addr")
+		}
+		printDNSResult(p.host, result)
+		// SRV answers carry their own host:port and are dialable as-is; every
+		// other non-address record type (CNAME/MX/TXT/PTR) is a lookup result,
+		// not a dial target, so report it but don't hand it to the dialer.
+		if DefaultDNSQType != "SRV" {
+			return fmt.Errorf("-qtype %s resolves records for display only; use -qtype A, AAAA or SRV to ping", DefaultDNSQType)
+		}
+		host, portStr, err := net.SplitHostPort(result.Addrs[0])
+		if err != nil {
+			return fmt.Errorf("srv record %q has no port: %w", result.Addrs[0], err)
+		}
+		port, err := strconv.Atoi(portStr)
+		if err != nil {
+			return fmt.Errorf("srv record %q has an invalid port: %w", result.Addrs[0], err)
+		}
+		p.port = port
+		p.resolved = []ResolvedAddr{{Addr: host, Family: family(host)}}
+		return nil
 	}
-	t := time.Now()
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(DefaultTimeout)*time.Second)
-	defer cancel()
-	addr, err := r.LookupHost(ctx, p.host)
-	if err == nil {
-		if len(addr) > 0 {
-			if strings.ContainsRune(addr[0], ':') {
-				p.addr = fmt.Sprintf("[%s]", addr[0])
-			} else {
-				p.addr = addr[0]
+
+	if DefaultDNSAddr != "" {
+		qtypes := qtypesForFamily(DefaultFamily)
+		// An explicit "-qtype A" or "-qtype AAAA" asks for exactly that record
+		// type; don't let -family auto/both silently widen it back to both.
+		if qtypeExplicit && (DefaultDNSQType == "A" || DefaultDNSQType == "AAAA") {
+			qtypes = []string{DefaultDNSQType}
+		}
+		for _, qtype := range qtypes {
+			resolver := NewResolver(DefaultDNSAddr, DefaultDNSNet, qtype, DefaultTimeout, DefaultDNSSNI, DefaultDNSInsecure)
+			result, err := resolver.Query(p.host)
+			if err != nil {
+				continue
 			}
-			if c {
-				fmt.Printf("[DNS] [%s] %s --> %s - %s\n", DefaultDNSAddr, p.host, p.addr, time.Since(t))
+			printDNSResult(p.host, result)
+			for _, addr := range result.Addrs {
+				p.resolved = append(p.resolved, ResolvedAddr{Addr: addr, Family: family(addr)})
 			}
+		}
+		if len(p.resolved) == 0 {
+			return errors.New("not found addr")
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(DefaultTimeout)*time.Second)
+	defer cancel()
+	wantV4, wantV6 := DefaultFamily != "6", DefaultFamily != "4"
+
+	// A dialer that can resolve hostnames itself (e.g. netstack.Net routing
+	// DNS through the WireGuard tunnel's own configured servers) takes
+	// priority over the host's resolver, which generally can't reach the
+	// tunnel peer's network at all.
+	if hr, ok := p.dialer.(HostResolver); ok {
+		addrs, err := hr.LookupContextHost(ctx, p.host)
+		if err != nil {
 			return err
 		}
-		err = errors.New("not found addr")
+		for _, addr := range addrs {
+			f := family(addr)
+			if (f == 4 && !wantV4) || (f == 6 && !wantV6) {
+				continue
+			}
+			p.resolved = append(p.resolved, ResolvedAddr{Addr: addr, Family: f})
+		}
+		if len(p.resolved) == 0 {
+			return errors.New("not found addr")
+		}
+		return nil
+	}
+
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return p.dialer.DialContext(ctx, network, address)
+		},
+	}
+	ips, err := r.LookupIPAddr(ctx, p.host)
+	if err != nil {
+		return err
+	}
+	for _, ip := range ips {
+		f := 6
+		if ip.IP.To4() != nil {
+			f = 4
+		}
+		if (f == 4 && !wantV4) || (f == 6 && !wantV6) {
+			continue
+		}
+		p.resolved = append(p.resolved, ResolvedAddr{Addr: ip.IP.String(), Family: f})
 	}
-	return err
+	if len(p.resolved) == 0 {
+		return errors.New("not found addr")
+	}
+	return nil
 }
 
-func (p *Ping) Ping() *Stats {
-	stats := &Stats{
-		Time: time.Now(),
+func printDNSResult(host string, result *DNSResult) {
+	if result.TLSVersion != "" {
+		fmt.Printf("[DNS] [%s/%s] %s %s --> %s - rcode=%d %s (%s, %s)\n", result.Server, result.Net, result.QType, host, strings.Join(result.Addrs, ","), result.RCode, result.Duration, result.TLSVersion, result.TLSCipherSuite)
+	} else {
+		fmt.Printf("[DNS] [%s/%s] %s %s --> %s - rcode=%d %s\n", result.Server, result.Net, result.QType, host, strings.Join(result.Addrs, ","), result.RCode, result.Duration)
 	}
-	if p.addr == "" {
-		stats.Error = errors.New("invalid host")
-		return stats
+}
+
+// family classifies an IP literal as 4 or 6; it defaults to 4 when addr
+// doesn't parse as an IP (e.g. a CNAME target), since dialing will surface
+// the real error.
+func family(addr string) int {
+	ip := net.ParseIP(addr)
+	if ip != nil && ip.To4() == nil {
+		return 6
 	}
+	return 4
+}
+
+func (p *Ping) normalize() {
 	if p.port <= 0 {
 		p.port = DefaultPort
 	}
@@ -110,13 +241,21 @@ func (p *Ping) Ping() *Stats {
 	if p.net == "" {
 		p.net = DefaultNet
 	}
-	dialer := &net.Dialer{
-		Timeout: time.Duration(p.timeout) * time.Second,
-	}
-	stats.Host = p.host
-	stats.DAddr = fmt.Sprintf("%s:%d", p.addr, p.port)
+	// -i 0 makes Do spawn a new probing goroutine per iteration, all sharing
+	// this *Ping, so the lazy dialer init has to run exactly once.
+	p.dialerOnce.Do(func() {
+		if p.dialer == nil {
+			p.dialer = &net.Dialer{Timeout: time.Duration(p.timeout) * time.Second}
+		}
+	})
+}
+
+// dial connects to a single resolved address and fills in a Stats record.
+func (p *Ping) dial(ctx context.Context, addr ResolvedAddr) *Stats {
+	stats := &Stats{Host: p.host, Family: addr.Family}
+	stats.DAddr = net.JoinHostPort(addr.Addr, strconv.Itoa(p.port))
 	stats.Time = time.Now()
-	conn, err := dialer.DialContext(context.Background(), p.net, stats.DAddr)
+	conn, err := p.dialer.DialContext(ctx, p.net, stats.DAddr)
 	stats.Duration = time.Since(stats.Time)
 	if conn != nil {
 		defer conn.Close()
@@ -129,35 +268,143 @@ func (p *Ping) Ping() *Stats {
 	return stats
 }
 
+// Probe runs one round of pings according to -family: "4"/"6" force a single
+// family, "both" measures both families independently every round, and
+// "auto" (the default) races them Happy-Eyeballs style and returns the
+// winner.
+func (p *Ping) Probe() []*Stats {
+	p.normalize()
+	if len(p.resolved) == 0 {
+		return []*Stats{{Time: time.Now(), Host: p.host, Error: errors.New("invalid host")}}
+	}
+	switch DefaultFamily {
+	case "both":
+		return p.pingBoth()
+	case "4", "6":
+		return []*Stats{p.pingNext()}
+	default:
+		return []*Stats{p.pingAuto()}
+	}
+}
+
+// pingNext advances the round-robin cursor across every resolved address
+// (interleaved by family for the default "auto" mode, or already filtered
+// to a single family for "4"/"6"). addrIdx is shared by every goroutine
+// Do spawns when -i is 0, so it's advanced atomically rather than read then
+// incremented.
+func (p *Ping) pingNext() *Stats {
+	idx := atomic.AddInt64(&p.addrIdx, 1) - 1
+	addr := p.resolved[idx%int64(len(p.resolved))]
+	return p.dial(context.Background(), addr)
+}
+
+// pingBoth dials the next IPv4 address and the next IPv6 address for this
+// round, so per-family stats accumulate independently of which one is
+// faster.
+func (p *Ping) pingBoth() []*Stats {
+	var v4, v6 []ResolvedAddr
+	for _, a := range p.resolved {
+		if a.Family == 6 {
+			v6 = append(v6, a)
+		} else {
+			v4 = append(v4, a)
+		}
+	}
+	idx := atomic.AddInt64(&p.addrIdx, 1) - 1
+	var out []*Stats
+	if len(v4) > 0 {
+		out = append(out, p.dial(context.Background(), v4[idx%int64(len(v4))]))
+	}
+	if len(v6) > 0 {
+		out = append(out, p.dial(context.Background(), v6[idx%int64(len(v6))]))
+	}
+	return out
+}
+
 func (s *Summary) Stats() {
 	count := s.Count - s.ErrCount
 	if count > 0 {
 		s.AVG = s.SUM / time.Duration(count)
 	}
-	fmt.Printf("\n[%s] Max: %s Min: %s Avg: %s Total: %d Error: %d - %s\n\n", strings.ToUpper(s.NET), s.MAX, s.MIN, s.AVG, s.Count, s.ErrCount, time.Since(s.Time))
+	for _, fs := range s.Families {
+		if fcount := fs.Count - fs.ErrCount; fcount > 0 {
+			fs.AVG = fs.SUM / time.Duration(fcount)
+		}
+	}
+	for _, as := range s.Addrs {
+		if acount := as.Count - as.ErrCount; acount > 0 {
+			as.AVG = as.SUM / time.Duration(acount)
+		}
+	}
+	s.Out.Final(s)
 }
 
 func (s *Summary) Result(ping *Ping) {
 	defer s.WG.Done()
-	stats := ping.Ping()
+	for _, stats := range ping.Probe() {
+		s.record(stats)
+	}
+}
+
+func (s *Summary) record(stats *Stats) {
 	s.Lock.Lock()
+	defer s.Lock.Unlock()
 	s.Count += 1
+
+	fs := s.Families[stats.Family]
+	if fs == nil {
+		fs = &familyStats{}
+		s.Families[stats.Family] = fs
+	}
+	fs.Count += 1
+
+	// stats.DAddr is empty only for a pre-resolve failure (e.g. "invalid
+	// host"), which never dialed a specific address and so has nothing to
+	// attribute to one.
+	var as *familyStats
+	if stats.DAddr != "" {
+		as = s.Addrs[stats.DAddr]
+		if as == nil {
+			as = &familyStats{}
+			s.Addrs[stats.DAddr] = as
+		}
+		as.Count += 1
+	}
+
 	if stats.Error == nil {
-		fmt.Printf("[%s] %s --> %s - %s\n", stats.Time.Format("2006/01/02 15:04:05"), stats.SAddr, stats.DAddr, stats.Duration)
 		if s.MIN > stats.Duration || s.MIN == 0 {
 			s.MIN = stats.Duration
 		}
 		if s.MAX < stats.Duration {
 			s.MAX = stats.Duration
 		}
-
 		s.SUM += stats.Duration
+
+		if fs.MIN > stats.Duration || fs.MIN == 0 {
+			fs.MIN = stats.Duration
+		}
+		if fs.MAX < stats.Duration {
+			fs.MAX = stats.Duration
+		}
+		fs.SUM += stats.Duration
+
+		if as != nil {
+			if as.MIN > stats.Duration || as.MIN == 0 {
+				as.MIN = stats.Duration
+			}
+			if as.MAX < stats.Duration {
+				as.MAX = stats.Duration
+			}
+			as.SUM += stats.Duration
+		}
 	} else {
 		s.ErrCount += 1
-		fmt.Printf("[%s] %s:%d - %s\n", stats.Time.Format("2006/01/02 15:04:05"), ping.host, ping.port, stats.Error.Error())
+		fs.ErrCount += 1
+		if as != nil {
+			as.ErrCount += 1
+		}
 	}
-	s.Lock.Unlock()
-	return
+	s.Out.Probe(stats)
 }
 
 func (p *Ping) Do(s *Summary) {
@@ -188,61 +435,107 @@ func (p *Ping) Do(s *Summary) {
 }
 
 func init() {
-	flag.StringVar(&DefaultDNSAddr, "dns", "", "Use DNS IP:PORT")
-	flag.StringVar(&DefaultDNSNet, "dns-net", "udp", "Use DNS Net")
+	flag.StringVar(&DefaultDNSAddr, "dns", "", "Use DNS IP:PORT (comma separated for failover, e.g. 1.1.1.1:53,8.8.8.8:53); a https:// URL for -dns-net https")
+	flag.StringVar(&DefaultDNSNet, "dns-net", "udp", "Use DNS Net: udp, tcp, tls (DoT) or https (DoH)")
+	flag.StringVar(&DefaultDNSQType, "qtype", "A", "DNS query type: A, AAAA, CNAME, MX, SRV, TXT, PTR")
+	flag.StringVar(&DefaultDNSSNI, "dns-sni", "", "TLS SNI/ServerName for -dns-net tls/https")
+	flag.BoolVar(&DefaultDNSInsecure, "dns-insecure", false, "Skip TLS certificate verification for -dns-net tls/https")
+	flag.StringVar(&DefaultFamily, "family", "auto", "Address family: auto (Happy Eyeballs), 4, 6 or both")
+	flag.StringVar(&DefaultNetstack, "netstack", "", "Dial through a user-space WireGuard tunnel using this wg-quick(8) style config file")
+	flag.StringVar(&DefaultOutput, "o", "text", "Output format: text, json, csv, influx or prom")
+	flag.StringVar(&DefaultListen, "listen", "", "Serve Prometheus metrics on this address (e.g. :9101) instead of exiting after -c probes")
 	flag.StringVar(&DefaultNet, "n", "tcp", "Use Net")
-	flag.StringVar(&DefaultHost, "h", "", "Ping Host")
+	flag.Var(&Hosts, "h", "Ping Host (host or host:port); repeat to probe multiple targets")
+	flag.StringVar(&DefaultTargetsFile, "f", "", "Read targets (one host[:port] per line) from this file")
+	flag.IntVar(&DefaultParallel, "parallel", 0, "Max targets probed concurrently (0 = all at once)")
 	flag.IntVar(&DefaultInterval, "i", 1, "Ping Interval")
 	flag.IntVar(&DefaultTimeout, "w", 1, "Ping Timeout")
 	flag.IntVar(&DefaultCount, "c", 10, "Ping Count")
 	flag.IntVar(&DefaultPort, "p", 80, "Default TCP Port.")
+}
+
+// parseFlags parses the command line and validates/normalizes the flags that
+// need it. It's called from main rather than init so that `go test` (which
+// never calls main) doesn't trip over flag.Parse() seeing the test binary's
+// own -test.* flags.
+func parseFlags() {
 	flag.Parse()
 
-	if DefaultHost == "" {
-		switch flag.NArg() {
-		case 1:
-			DefaultHost = flag.Args()[0]
-		case 2:
-			DefaultHost = flag.Args()[0]
-			prot, err := strconv.Atoi(flag.Args()[1])
-			if err != nil {
-				DefaultHost = ""
-			}
-			DefaultPort = prot
-		default:
-			DefaultHost = ""
+	DefaultFamily = strings.ToLower(DefaultFamily)
+	switch DefaultFamily {
+	case "auto", "4", "6", "both":
+	default:
+		fmt.Printf("Invalid -family %q, expected auto, 4, 6 or both.\n", DefaultFamily)
+		os.Exit(127)
+	}
+
+	DefaultDNSQType = strings.ToUpper(DefaultDNSQType)
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "qtype" {
+			qtypeExplicit = true
 		}
+	})
+
+	if DefaultListen != "" {
+		DefaultOutput = "prom"
+		DefaultCount = -1
 	}
+}
+
+func main() {
+	parseFlags()
 
-	if DefaultHost == "" || DefaultPort == 0 {
-		fmt.Printf("Use '-h' to set host, '-p' to set port.\n")
+	targets, err := collectTargets(Hosts, DefaultTargetsFile, flag.Args(), DefaultPort)
+	if err != nil {
+		fmt.Println(err.Error())
 		os.Exit(127)
 	}
-}
+	if len(targets) == 0 {
+		fmt.Printf("Use '-h' to set a host, '-f' to read targets from a file, or pass host[:port] arguments.\n")
+		os.Exit(127)
+	}
+
+	var dialer Dialer
+	if DefaultNetstack != "" {
+		dialer, err = NewNetstackDialer(DefaultNetstack)
+		if err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	}
+
+	out, err := NewOutput(DefaultOutput)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(127)
+	}
+	if DefaultListen != "" {
+		go func() {
+			if err := ListenAndServePrometheus(DefaultListen); err != nil {
+				fmt.Println(err.Error())
+				os.Exit(1)
+			}
+		}()
+	}
 
-func (s *Summary) Interrupt() {
-	c := make(chan os.Signal, 2)
-	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-	go func() {
-		<-c
-		s.Stats()
-		os.Exit(1)
-	}()
+	runner := NewRunner(targets, DefaultParallel, out, dialer)
+	runner.InstallInterrupt()
+	runner.Run()
+	// The table is a human-readable convenience; structured formats (json,
+	// csv, influx, prom) already printed everything per-target and appending
+	// it would break naive line-oriented consumers of their output.
+	if len(targets) > 1 && isTextOutput(DefaultOutput) {
+		runner.PrintTable()
+	}
 }
 
-func main() {
-	ping := Ping{
-		net:     strings.ToLower(DefaultNet),
-		host:    DefaultHost,
-		port:    DefaultPort,
-		timeout: DefaultTimeout,
-	}
-	summary := &Summary{
-		NET:  ping.net,
-		Time: time.Now(),
-		Lock: &sync.Mutex{},
-		WG:   &sync.WaitGroup{},
-	}
-	summary.Interrupt()
-	ping.Do(summary)
+// isTextOutput reports whether name selects the human-readable table output,
+// matching NewOutput's own "" (default) and "text" cases.
+func isTextOutput(name string) bool {
+	switch strings.ToLower(name) {
+	case "", "text":
+		return true
+	default:
+		return false
+	}
 }
@@ -0,0 +1,159 @@
+//go:build netstack
+
+package main
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/device"
+	"golang.zx2c4.com/wireguard/tun/netstack"
+)
+
+// wgConfig is the subset of a wg-quick(8) config file tcping needs to bring
+// up a user-space tunnel: one local interface and one peer.
+type wgConfig struct {
+	PrivateKey string
+	Address    []netip.Addr
+	DNS        []netip.Addr
+	PublicKey  string
+	Endpoint   string
+	AllowedIPs string
+}
+
+// parseWGConfig reads a wg-quick(8) style [Interface]/[Peer] file. It only
+// understands the handful of keys tcping needs to dial out; anything else is
+// ignored.
+func parseWGConfig(path string) (*wgConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg := &wgConfig{}
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(strings.Trim(line, "[]"))
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch section {
+		case "interface":
+			switch key {
+			case "privatekey":
+				cfg.PrivateKey = value
+			case "address":
+				for _, a := range strings.Split(value, ",") {
+					a = strings.TrimSpace(strings.SplitN(a, "/", 2)[0])
+					if ip, err := netip.ParseAddr(a); err == nil {
+						cfg.Address = append(cfg.Address, ip)
+					}
+				}
+			case "dns":
+				for _, a := range strings.Split(value, ",") {
+					if ip, err := netip.ParseAddr(strings.TrimSpace(a)); err == nil {
+						cfg.DNS = append(cfg.DNS, ip)
+					}
+				}
+			}
+		case "peer":
+			switch key {
+			case "publickey":
+				cfg.PublicKey = value
+			case "endpoint":
+				cfg.Endpoint = value
+			case "allowedips":
+				cfg.AllowedIPs = value
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if cfg.PrivateKey == "" || cfg.PublicKey == "" || cfg.Endpoint == "" {
+		return nil, fmt.Errorf("netstack: config is missing PrivateKey, PublicKey or Endpoint")
+	}
+	if len(cfg.Address) == 0 {
+		return nil, fmt.Errorf("netstack: config is missing an [Interface] Address")
+	}
+	if cfg.AllowedIPs == "" {
+		cfg.AllowedIPs = "0.0.0.0/0,::/0"
+	}
+	return cfg, nil
+}
+
+// wgKeyToHex converts a wg-quick base64 key to the hex encoding the WireGuard
+// UAPI (device.IpcSet) expects.
+func wgKeyToHex(key string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil {
+		return "", fmt.Errorf("invalid wireguard key %q: %w", key, err)
+	}
+	if len(raw) != 32 {
+		return "", fmt.Errorf("invalid wireguard key %q: want 32 bytes, got %d", key, len(raw))
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// NewNetstackDialer brings up a user-space WireGuard tunnel, backed by a
+// gVisor netstack, from a wg-quick(8) style config file and returns its
+// dialer. All TCP connections made through the returned Dialer travel
+// through the tunnel instead of the host kernel's network stack.
+func NewNetstackDialer(confPath string) (Dialer, error) {
+	cfg, err := parseWGConfig(confPath)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := wgKeyToHex(cfg.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	publicKey, err := wgKeyToHex(cfg.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	tunDev, tnet, err := netstack.CreateNetTUN(cfg.Address, cfg.DNS, 1420)
+	if err != nil {
+		return nil, fmt.Errorf("netstack: create tun: %w", err)
+	}
+
+	dev := device.NewDevice(tunDev, conn.NewDefaultBind(), device.NewLogger(device.LogLevelError, "tcping-netstack: "))
+	var uapi strings.Builder
+	fmt.Fprintf(&uapi, "private_key=%s\n", privateKey)
+	fmt.Fprintf(&uapi, "public_key=%s\n", publicKey)
+	fmt.Fprintf(&uapi, "endpoint=%s\n", cfg.Endpoint)
+	for _, allowed := range strings.Split(cfg.AllowedIPs, ",") {
+		fmt.Fprintf(&uapi, "allowed_ip=%s\n", strings.TrimSpace(allowed))
+	}
+	if err := dev.IpcSet(uapi.String()); err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("netstack: configure device: %w", err)
+	}
+	if err := dev.Up(); err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("netstack: bring device up: %w", err)
+	}
+
+	return tnet, nil
+}
@@ -0,0 +1,12 @@
+//go:build !netstack
+
+package main
+
+import "fmt"
+
+// NewNetstackDialer is stubbed out in the default build; the gVisor and
+// WireGuard dependencies it needs are only pulled in when built with
+// `-tags netstack`.
+func NewNetstackDialer(confPath string) (Dialer, error) {
+	return nil, fmt.Errorf("tcping: built without WireGuard netstack support, rebuild with -tags netstack")
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Output renders probe results and the final report. Selected via -o:
+// text (the original human-readable format, default), json, csv or influx.
+type Output interface {
+	Probe(stats *Stats)
+	Final(s *Summary)
+}
+
+// NewOutput builds the Output implementation named by -o. The result is
+// shared by every target Runner probes concurrently, so it's wrapped in a
+// syncOutput to serialize access to implementations (csvOutput in
+// particular) that aren't safe for concurrent use on their own.
+func NewOutput(name string) (Output, error) {
+	out, err := newOutput(name)
+	if err != nil {
+		return nil, err
+	}
+	return &syncOutput{out: out}, nil
+}
+
+func newOutput(name string) (Output, error) {
+	switch strings.ToLower(name) {
+	case "", "text":
+		return &textOutput{}, nil
+	case "json":
+		return &jsonOutput{}, nil
+	case "csv":
+		return &csvOutput{w: csv.NewWriter(os.Stdout)}, nil
+	case "influx":
+		return &influxOutput{}, nil
+	case "prom":
+		return newPromOutput(), nil
+	default:
+		return nil, fmt.Errorf("unknown -o format %q: want text, json, csv, influx or prom", name)
+	}
+}
+
+// syncOutput serializes Probe/Final calls onto the wrapped Output so
+// multiple targets sharing one instance (the common case once Runner hands
+// every target the same Output) don't race on shared state such as
+// csvOutput's bufio.Writer.
+type syncOutput struct {
+	mu  sync.Mutex
+	out Output
+}
+
+func (o *syncOutput) Probe(stats *Stats) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.out.Probe(stats)
+}
+
+func (o *syncOutput) Final(s *Summary) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.out.Final(s)
+}
+
+type textOutput struct{}
+
+func (textOutput) Probe(stats *Stats) {
+	if stats.Error == nil {
+		fmt.Printf("[%s] %s --> %s - %s\n", stats.Time.Format("2006/01/02 15:04:05"), stats.SAddr, stats.DAddr, stats.Duration)
+	} else {
+		fmt.Printf("[%s] %s - %s\n", stats.Time.Format("2006/01/02 15:04:05"), stats.Host, stats.Error.Error())
+	}
+}
+
+func (textOutput) Final(s *Summary) {
+	fmt.Printf("\n[%s] Max: %s Min: %s Avg: %s Total: %d Error: %d - %s\n", strings.ToUpper(s.NET), s.MAX, s.MIN, s.AVG, s.Count, s.ErrCount, time.Since(s.Time))
+	for _, f := range []int{4, 6} {
+		fs := s.Families[f]
+		if fs == nil || fs.Count == 0 {
+			continue
+		}
+		loss := float64(fs.ErrCount) / float64(fs.Count) * 100
+		fmt.Printf("[IPv%d] Max: %s Min: %s Avg: %s Total: %d Loss: %.1f%%\n", f, fs.MAX, fs.MIN, fs.AVG, fs.Count, loss)
+	}
+	if len(s.Addrs) > 1 {
+		addrs := make([]string, 0, len(s.Addrs))
+		for addr := range s.Addrs {
+			addrs = append(addrs, addr)
+		}
+		sort.Strings(addrs)
+		for _, addr := range addrs {
+			as := s.Addrs[addr]
+			loss := float64(as.ErrCount) / float64(as.Count) * 100
+			fmt.Printf("[%s] Max: %s Min: %s Avg: %s Total: %d Loss: %.1f%%\n", addr, as.MAX, as.MIN, as.AVG, as.Count, loss)
+		}
+	}
+	fmt.Println()
+}
+
+// probeRecord is the JSON/line-protocol shape of a single probe.
+type probeRecord struct {
+	TS    string `json:"ts"`
+	Host  string `json:"host"`
+	SAddr string `json:"saddr,omitempty"`
+	DAddr string `json:"daddr,omitempty"`
+	RTTNs int64  `json:"rtt_ns"`
+	Err   string `json:"err,omitempty"`
+}
+
+func newProbeRecord(stats *Stats) probeRecord {
+	rec := probeRecord{
+		TS:    stats.Time.Format(time.RFC3339Nano),
+		Host:  stats.Host,
+		SAddr: stats.SAddr,
+		DAddr: stats.DAddr,
+		RTTNs: stats.Duration.Nanoseconds(),
+	}
+	if stats.Error != nil {
+		rec.Err = stats.Error.Error()
+	}
+	return rec
+}
+
+type jsonOutput struct{}
+
+func (jsonOutput) Probe(stats *Stats) {
+	b, err := json.Marshal(newProbeRecord(stats))
+	if err != nil {
+		return
+	}
+	fmt.Println(string(b))
+}
+
+func (jsonOutput) Final(s *Summary) {
+	b, err := json.Marshal(struct {
+		NET      string `json:"net"`
+		MaxNs    int64  `json:"max_ns"`
+		MinNs    int64  `json:"min_ns"`
+		AvgNs    int64  `json:"avg_ns"`
+		Count    int    `json:"count"`
+		ErrCount int    `json:"err_count"`
+	}{
+		NET:      s.NET,
+		MaxNs:    s.MAX.Nanoseconds(),
+		MinNs:    s.MIN.Nanoseconds(),
+		AvgNs:    s.AVG.Nanoseconds(),
+		Count:    s.Count,
+		ErrCount: s.ErrCount,
+	})
+	if err != nil {
+		return
+	}
+	fmt.Println(string(b))
+}
+
+type csvOutput struct {
+	w       *csv.Writer
+	once    sync.Once
+	headers bool
+}
+
+func (o *csvOutput) Probe(stats *Stats) {
+	o.once.Do(func() {
+		o.w.Write([]string{"ts", "host", "saddr", "daddr", "rtt_ns", "err"})
+	})
+	rec := newProbeRecord(stats)
+	o.w.Write([]string{rec.TS, rec.Host, rec.SAddr, rec.DAddr, strconv.FormatInt(rec.RTTNs, 10), rec.Err})
+	o.w.Flush()
+}
+
+func (o *csvOutput) Final(s *Summary) {
+	o.w.Write([]string{"#summary", s.NET, s.MAX.String(), s.MIN.String(), s.AVG.String(), strconv.Itoa(s.Count), strconv.Itoa(s.ErrCount)})
+	o.w.Flush()
+}
+
+type influxOutput struct{}
+
+func (influxOutput) Probe(stats *Stats) {
+	up := 1
+	if stats.Error != nil {
+		up = 0
+	}
+	fmt.Printf("tcping,host=%s,daddr=%s rtt_ns=%di,up=%di %d\n",
+		escapeTag(stats.Host), escapeTag(stats.DAddr), stats.Duration.Nanoseconds(), up, stats.Time.UnixNano())
+}
+
+func (influxOutput) Final(s *Summary) {
+	fmt.Printf("tcping_summary,net=%s max_ns=%di,min_ns=%di,avg_ns=%di,count=%di,err_count=%di %d\n",
+		escapeTag(s.NET), s.MAX.Nanoseconds(), s.MIN.Nanoseconds(), s.AVG.Nanoseconds(), s.Count, s.ErrCount, time.Now().UnixNano())
+}
+
+// escapeTag escapes the characters InfluxDB line protocol treats specially
+// in tag keys/values.
+func escapeTag(s string) string {
+	r := strings.NewReplacer(" ", "\\ ", ",", "\\,", "=", "\\=")
+	return r.Replace(s)
+}
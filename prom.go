@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// promOutput records every probe into Prometheus collectors instead of
+// printing it; the metrics are served by ListenAndServePrometheus.
+type promOutput struct {
+	rtt *prometheus.HistogramVec
+	up  *prometheus.GaugeVec
+}
+
+func newPromOutput() *promOutput {
+	o := &promOutput{
+		rtt: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "tcping_rtt_seconds",
+			Help:    "TCP connect round-trip time in seconds.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"host", "daddr"}),
+		up: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "tcping_up",
+			Help: "1 if the last probe connected successfully, 0 otherwise.",
+		}, []string{"host", "daddr"}),
+	}
+	prometheus.MustRegister(o.rtt, o.up)
+	return o
+}
+
+func (o *promOutput) Probe(stats *Stats) {
+	labels := prometheus.Labels{"host": stats.Host, "daddr": stats.DAddr}
+	if stats.Error == nil {
+		o.rtt.With(labels).Observe(stats.Duration.Seconds())
+		o.up.With(labels).Set(1)
+	} else {
+		o.up.With(labels).Set(0)
+	}
+}
+
+func (o *promOutput) Final(s *Summary) {}
+
+// ListenAndServePrometheus serves the registered tcping metrics for
+// Prometheus to scrape. It only returns if the HTTP server fails to start.
+func ListenAndServePrometheus(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return http.ListenAndServe(addr, mux)
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// qtypeMap maps the -qtype flag value to the corresponding dns.Type constant.
+var qtypeMap = map[string]uint16{
+	"A":     dns.TypeA,
+	"AAAA":  dns.TypeAAAA,
+	"CNAME": dns.TypeCNAME,
+	"MX":    dns.TypeMX,
+	"SRV":   dns.TypeSRV,
+	"TXT":   dns.TypeTXT,
+	"PTR":   dns.TypePTR,
+}
+
+// DNSResult carries the raw answer for a single query alongside the
+// information tcping reports to the user (RCODE, query latency, server used).
+type DNSResult struct {
+	Server         string
+	Net            string
+	QType          string
+	RCode          int
+	Duration       time.Duration
+	Msg            *dns.Msg
+	Addrs          []string
+	TLSVersion     string
+	TLSCipherSuite string
+}
+
+// Resolver is a small DNS client that fails over across multiple upstream
+// servers and falls back to TCP when a UDP reply comes back truncated. Net
+// may be "udp", "tcp", "tls" (DoT, RFC 7858) or "https" (DoH, RFC 8484); for
+// "https" the server string is the full query URL rather than an IP:PORT.
+type Resolver struct {
+	Servers  []string
+	Net      string
+	QType    string
+	Timeout  int
+	SNI      string
+	Insecure bool
+}
+
+// NewResolver builds a Resolver from the comma separated server list passed
+// on the command line (e.g. "1.1.1.1:53,8.8.8.8:53" or, for DoH,
+// "https://1.1.1.1/dns-query").
+func NewResolver(servers, network, qtype string, timeout int, sni string, insecure bool) *Resolver {
+	r := &Resolver{
+		Net:      strings.ToLower(network),
+		QType:    strings.ToUpper(qtype),
+		Timeout:  timeout,
+		SNI:      sni,
+		Insecure: insecure,
+	}
+	for _, s := range strings.Split(servers, ",") {
+		s = strings.TrimSpace(s)
+		if s != "" {
+			r.Servers = append(r.Servers, s)
+		}
+	}
+	return r
+}
+
+// Query resolves host against the configured query type, trying each
+// upstream server in order until one answers. A truncated UDP response is
+// retried over TCP against the same server before moving on.
+func (r *Resolver) Query(host string) (*DNSResult, error) {
+	qtype, ok := qtypeMap[r.QType]
+	if !ok {
+		qtype = dns.TypeA
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(host), qtype)
+	msg.RecursionDesired = true
+
+	if len(r.Servers) == 0 {
+		return nil, fmt.Errorf("no dns servers configured")
+	}
+
+	var lastErr error
+	for _, server := range r.Servers {
+		network := r.Net
+		if network == "" {
+			network = "udp"
+		}
+		in, rtt, state, err := r.exchange(msg, server, network)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if network == "udp" && in.Truncated {
+			in, rtt, state, err = r.exchange(msg, server, "tcp")
+			if err != nil {
+				lastErr = err
+				continue
+			}
+		}
+		result := &DNSResult{
+			Server:   server,
+			Net:      network,
+			QType:    r.QType,
+			RCode:    in.Rcode,
+			Duration: rtt,
+			Msg:      in,
+			Addrs:    extractAddrs(in, qtype),
+		}
+		if state != nil {
+			result.TLSVersion = tlsVersionName(state.Version)
+			result.TLSCipherSuite = tls.CipherSuiteName(state.CipherSuite)
+		}
+		return result, nil
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("dns query failed for all servers")
+	}
+	return nil, lastErr
+}
+
+// exchange sends msg to server over the given network and returns the
+// answer, the round-trip query latency and, for tls/https, the negotiated
+// TLS connection state.
+func (r *Resolver) exchange(msg *dns.Msg, server, network string) (*dns.Msg, time.Duration, *tls.ConnectionState, error) {
+	switch network {
+	case "tls":
+		return r.exchangeDoT(msg, server)
+	case "https":
+		return r.exchangeDoH(msg, server)
+	default:
+		c := &dns.Client{
+			Net:     network,
+			Timeout: time.Duration(r.Timeout) * time.Second,
+		}
+		in, rtt, err := c.Exchange(msg, server)
+		return in, rtt, nil, err
+	}
+}
+
+// exchangeDoT performs a DNS-over-TLS query (RFC 7858) against server,
+// defaulting to port 853 when none is given.
+func (r *Resolver) exchangeDoT(msg *dns.Msg, server string) (*dns.Msg, time.Duration, *tls.ConnectionState, error) {
+	server = ensurePort(server, "853")
+	conf := &tls.Config{
+		ServerName:         r.SNI,
+		InsecureSkipVerify: r.Insecure,
+	}
+	dialer := &net.Dialer{Timeout: time.Duration(r.Timeout) * time.Second}
+
+	start := time.Now()
+	conn, err := tls.DialWithDialer(dialer, "tcp", server, conf)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Duration(r.Timeout) * time.Second))
+
+	co := &dns.Conn{Conn: conn}
+	if err := co.WriteMsg(msg); err != nil {
+		return nil, 0, nil, err
+	}
+	in, err := co.ReadMsg()
+	rtt := time.Since(start)
+	if err != nil {
+		return nil, rtt, nil, err
+	}
+	state := conn.ConnectionState()
+	return in, rtt, &state, nil
+}
+
+// exchangeDoH performs a DNS-over-HTTPS query (RFC 8484) by POSTing the
+// wire-format message to server with a application/dns-message body.
+func (r *Resolver) exchangeDoH(msg *dns.Msg, server string) (*dns.Msg, time.Duration, *tls.ConnectionState, error) {
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	client := &http.Client{
+		Timeout: time.Duration(r.Timeout) * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{
+				ServerName:         r.SNI,
+				InsecureSkipVerify: r.Insecure,
+			},
+		},
+	}
+	req, err := http.NewRequest(http.MethodPost, server, bytes.NewReader(packed))
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	rtt := time.Since(start)
+	if err != nil {
+		return nil, rtt, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, rtt, nil, fmt.Errorf("doh: unexpected status %s", resp.Status)
+	}
+
+	in := new(dns.Msg)
+	if err := in.Unpack(body); err != nil {
+		return nil, rtt, nil, err
+	}
+	return in, rtt, resp.TLS, nil
+}
+
+// ensurePort appends defaultPort to addr if addr has no port of its own.
+func ensurePort(addr, defaultPort string) string {
+	if _, _, err := net.SplitHostPort(addr); err == nil {
+		return addr
+	}
+	return net.JoinHostPort(addr, defaultPort)
+}
+
+// tlsVersionName renders a tls.VersionTLSxx constant the way users expect
+// to see it, e.g. "TLS 1.3".
+func tlsVersionName(version uint16) string {
+	switch version {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("0x%04x", version)
+	}
+}
+
+// extractAddrs pulls the relevant record values out of a DNS answer section
+// for the query type that was asked.
+func extractAddrs(in *dns.Msg, qtype uint16) []string {
+	var out []string
+	for _, rr := range in.Answer {
+		switch qtype {
+		case dns.TypeA:
+			if a, ok := rr.(*dns.A); ok {
+				out = append(out, a.A.String())
+			}
+		case dns.TypeAAAA:
+			if a, ok := rr.(*dns.AAAA); ok {
+				out = append(out, a.AAAA.String())
+			}
+		case dns.TypeCNAME:
+			if a, ok := rr.(*dns.CNAME); ok {
+				out = append(out, a.Target)
+			}
+		case dns.TypeMX:
+			if a, ok := rr.(*dns.MX); ok {
+				out = append(out, a.Mx)
+			}
+		case dns.TypeSRV:
+			if a, ok := rr.(*dns.SRV); ok {
+				out = append(out, net.JoinHostPort(a.Target, strconv.Itoa(int(a.Port))))
+			}
+		case dns.TypeTXT:
+			if a, ok := rr.(*dns.TXT); ok {
+				out = append(out, strings.Join(a.Txt, " "))
+			}
+		case dns.TypePTR:
+			if a, ok := rr.(*dns.PTR); ok {
+				out = append(out, a.Ptr)
+			}
+		}
+	}
+	return out
+}
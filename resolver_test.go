@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestExtractAddrsSRVIncludesPort(t *testing.T) {
+	in := new(dns.Msg)
+	in.Answer = append(in.Answer, &dns.SRV{
+		Hdr:    dns.RR_Header{Name: "_svc._tcp.example.com.", Rrtype: dns.TypeSRV},
+		Target: "svc.example.com.",
+		Port:   8443,
+	})
+
+	got := extractAddrs(in, dns.TypeSRV)
+	want := []string{"svc.example.com.:8443"}
+	if len(got) != 1 || got[0] != want[0] {
+		t.Fatalf("extractAddrs(SRV) = %v, want %v", got, want)
+	}
+}
+
+func TestExtractAddrsA(t *testing.T) {
+	in := new(dns.Msg)
+	in.Answer = append(in.Answer,
+		&dns.A{Hdr: dns.RR_Header{Rrtype: dns.TypeA}, A: net.ParseIP("203.0.113.1")},
+		&dns.TXT{Hdr: dns.RR_Header{Rrtype: dns.TypeTXT}, Txt: []string{"ignored"}},
+	)
+
+	got := extractAddrs(in, dns.TypeA)
+	if len(got) != 1 || got[0] != "203.0.113.1" {
+		t.Fatalf("extractAddrs(A) = %v, want [203.0.113.1]", got)
+	}
+}
+
+func TestEnsurePort(t *testing.T) {
+	cases := []struct{ addr, want string }{
+		{"1.1.1.1", "1.1.1.1:853"},
+		{"1.1.1.1:53", "1.1.1.1:53"},
+		{"[::1]:53", "[::1]:53"},
+	}
+	for _, c := range cases {
+		if got := ensurePort(c.addr, "853"); got != c.want {
+			t.Errorf("ensurePort(%q, 853) = %q, want %q", c.addr, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// hostList collects every -h flag occurrence into a slice, so "-h a -h b"
+// pings both a and b instead of the last one winning.
+type hostList []string
+
+func (h *hostList) String() string { return strings.Join(*h, ",") }
+func (h *hostList) Set(v string) error {
+	*h = append(*h, v)
+	return nil
+}
+
+// Target is one host:port pair to probe.
+type Target struct {
+	Host string
+	Port int
+}
+
+func (t Target) String() string { return net.JoinHostPort(t.Host, strconv.Itoa(t.Port)) }
+
+// parseTarget splits "host:port" into a Target, falling back to defaultPort
+// when raw has no port of its own (a bare host, or a bracketed/bare IPv6
+// literal).
+func parseTarget(raw string, defaultPort int) (Target, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return Target{}, fmt.Errorf("empty target")
+	}
+	host, portStr, err := net.SplitHostPort(raw)
+	if err != nil {
+		return Target{Host: raw, Port: defaultPort}, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return Target{}, fmt.Errorf("invalid port in %q: %w", raw, err)
+	}
+	return Target{Host: host, Port: port}, nil
+}
+
+// readTargetsFile reads one target per line from path, skipping blank lines
+// and #-comments.
+func readTargetsFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+// collectTargets gathers targets from repeated -h flags, -f targets.txt and
+// positional arguments, in that order. A lone "host port" positional pair is
+// kept as one target for backwards compatibility with tcping's original
+// single-target invocation.
+func collectTargets(hosts hostList, targetsFile string, args []string, defaultPort int) ([]Target, error) {
+	var raw []string
+	raw = append(raw, hosts...)
+
+	if targetsFile != "" {
+		lines, err := readTargetsFile(targetsFile)
+		if err != nil {
+			return nil, fmt.Errorf("-f %s: %w", targetsFile, err)
+		}
+		raw = append(raw, lines...)
+	}
+
+	if len(raw) == 0 && len(args) == 2 {
+		if port, err := strconv.Atoi(args[1]); err == nil {
+			raw = append(raw, net.JoinHostPort(args[0], strconv.Itoa(port)))
+			args = nil
+		}
+	}
+	raw = append(raw, args...)
+
+	targets := make([]Target, 0, len(raw))
+	for _, r := range raw {
+		t, err := parseTarget(r, defaultPort)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	return targets, nil
+}
+
+// TargetResult pairs a Target with the Summary accumulating its probes.
+type TargetResult struct {
+	Target  Target
+	Summary *Summary
+}
+
+// Runner drives concurrent probing of every target, each with its own
+// Summary, and reports the aggregate once all of them finish.
+type Runner struct {
+	Parallel int
+	Results  []*TargetResult
+	Dialer   Dialer
+}
+
+// NewRunner builds a Runner with one Summary per target, ready to run.
+func NewRunner(targets []Target, parallel int, out Output, dialer Dialer) *Runner {
+	results := make([]*TargetResult, len(targets))
+	for i, t := range targets {
+		results[i] = &TargetResult{
+			Target: t,
+			Summary: &Summary{
+				NET:      strings.ToLower(DefaultNet),
+				Time:     time.Now(),
+				Lock:     &sync.Mutex{},
+				WG:       &sync.WaitGroup{},
+				Families: map[int]*familyStats{},
+				Addrs:    map[string]*familyStats{},
+				Out:      out,
+			},
+		}
+	}
+	return &Runner{Parallel: parallel, Results: results, Dialer: dialer}
+}
+
+// InstallInterrupt prints every target's current Summary and exits on
+// SIGINT/SIGTERM, the multi-target equivalent of the single-target
+// Summary.Interrupt.
+func (r *Runner) InstallInterrupt() {
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-c
+		for _, res := range r.Results {
+			res.Summary.Lock.Lock()
+			res.Summary.Stats()
+			res.Summary.Lock.Unlock()
+		}
+		os.Exit(1)
+	}()
+}
+
+// Run probes every target concurrently, bounded to Parallel goroutines at a
+// time (0 means unbounded), and blocks until they have all finished.
+func (r *Runner) Run() {
+	limit := r.Parallel
+	if limit <= 0 {
+		limit = len(r.Results)
+	}
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for _, res := range r.Results {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(res *TargetResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			ping := &Ping{
+				net:     strings.ToLower(DefaultNet),
+				host:    res.Target.Host,
+				port:    res.Target.Port,
+				timeout: DefaultTimeout,
+				dialer:  r.Dialer,
+			}
+			ping.Do(res.Summary)
+		}(res)
+	}
+	wg.Wait()
+}
+
+// loss returns the percentage of probes that errored for s.
+func loss(s *Summary) float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.ErrCount) / float64(s.Count) * 100
+}
+
+// PrintTable prints a one-line-per-target report sorted by loss, then
+// average latency, then minimum latency.
+func (r *Runner) PrintTable() {
+	sort.Slice(r.Results, func(i, j int) bool {
+		si, sj := r.Results[i].Summary, r.Results[j].Summary
+		if li, lj := loss(si), loss(sj); li != lj {
+			return li < lj
+		}
+		if si.AVG != sj.AVG {
+			return si.AVG < sj.AVG
+		}
+		return si.MIN < sj.MIN
+	})
+	fmt.Printf("\n%-40s %8s %10s %10s %10s %8s\n", "TARGET", "COUNT", "MIN", "AVG", "MAX", "LOSS")
+	for _, res := range r.Results {
+		s := res.Summary
+		fmt.Printf("%-40s %8d %10s %10s %10s %7.1f%%\n", res.Target.String(), s.Count, s.MIN, s.AVG, s.MAX, loss(s))
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestParseTarget(t *testing.T) {
+	cases := []struct {
+		raw         string
+		defaultPort int
+		want        Target
+		wantErr     bool
+	}{
+		{"example.com", 80, Target{Host: "example.com", Port: 80}, false},
+		{"example.com:443", 80, Target{Host: "example.com", Port: 443}, false},
+		{"[2001:db8::1]:22", 80, Target{Host: "2001:db8::1", Port: 22}, false},
+		{"example.com:notaport", 80, Target{}, true},
+		{"", 80, Target{}, true},
+	}
+	for _, c := range cases {
+		got, err := parseTarget(c.raw, c.defaultPort)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("parseTarget(%q) = %v, want error", c.raw, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseTarget(%q) unexpected error: %v", c.raw, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("parseTarget(%q) = %+v, want %+v", c.raw, got, c.want)
+		}
+	}
+}
+
+func TestCollectTargetsMergesHostsAndArgs(t *testing.T) {
+	targets, err := collectTargets(hostList{"a.example.com", "b.example.com:8080"}, "", nil, 80)
+	if err != nil {
+		t.Fatalf("collectTargets returned error: %v", err)
+	}
+	want := []Target{{Host: "a.example.com", Port: 80}, {Host: "b.example.com", Port: 8080}}
+	if len(targets) != len(want) {
+		t.Fatalf("collectTargets = %v, want %v", targets, want)
+	}
+	for i, tg := range targets {
+		if tg != want[i] {
+			t.Errorf("collectTargets[%d] = %+v, want %+v", i, tg, want[i])
+		}
+	}
+}
+
+func TestCollectTargetsSingleHostPortArgsIsOneTarget(t *testing.T) {
+	targets, err := collectTargets(nil, "", []string{"example.com", "8080"}, 80)
+	if err != nil {
+		t.Fatalf("collectTargets returned error: %v", err)
+	}
+	want := Target{Host: "example.com", Port: 8080}
+	if len(targets) != 1 || targets[0] != want {
+		t.Fatalf("collectTargets(host, port args) = %v, want [%+v]", targets, want)
+	}
+}